@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+// ChangeOp identifies the kind of filesystem change a Change describes.
+type ChangeOp string
+
+// The operations a --changes-file entry may specify. The zero value ("")
+// is used internally for changes synthesized from a plain --include path
+// list, where makeTree falls back to inferring create/modify/delete by
+// stating the path, exactly as it always has.
+const (
+	ChangeCreate ChangeOp = "create"
+	ChangeModify ChangeOp = "modify"
+	ChangeDelete ChangeOp = "delete"
+	ChangeRename ChangeOp = "rename"
+)
+
+// Change is a single filesystem event, either read from a --changes-file
+// (JSON array or newline-delimited JSON stream) or synthesized from a
+// plain --include path list.
+type Change struct {
+	Op   ChangeOp `json:"op"`
+	Path string   `json:"path,omitempty"`
+	From string   `json:"from,omitempty"`
+	To   string   `json:"to,omitempty"`
+
+	// oldNode is filled in by ChangeSet.resolveRenames for ChangeRename
+	// entries, so makeTree can reuse its content blobs instead of
+	// re-reading the file at To.
+	oldNode *restic.Node
+}
+
+func (c Change) validate() error {
+	switch c.Op {
+	case ChangeCreate, ChangeModify, ChangeDelete:
+		if c.Path == "" {
+			return errors.Fatalf("--changes-file: %q entry is missing \"path\"", c.Op)
+		}
+	case ChangeRename:
+		if c.From == "" || c.To == "" {
+			return errors.Fatal("--changes-file: \"rename\" entry requires both \"from\" and \"to\"")
+		}
+	default:
+		return errors.Fatalf("--changes-file: unknown op %q", c.Op)
+	}
+	return nil
+}
+
+// ChangeSet is the input to makeTree: the set of absolute paths that may
+// have changed since the parent snapshot, together with enough detail
+// (when known) to avoid re-deriving it by stating the filesystem.
+type ChangeSet struct {
+	paths  []string // sorted, de-duplicated
+	byPath map[string]Change
+}
+
+// NewChangeSet builds a ChangeSet from explicit changes, as read from a
+// --changes-file. A rename is split into two entries: its "to" path keeps
+// the ChangeRename op so makeTree can reuse content, and its "from" path
+// becomes a plain delete.
+func NewChangeSet(changes []Change) (*ChangeSet, error) {
+	cs := &ChangeSet{byPath: make(map[string]Change, len(changes))}
+	for _, c := range changes {
+		if err := c.validate(); err != nil {
+			return nil, err
+		}
+		switch c.Op {
+		case ChangeRename:
+			cs.byPath[c.To] = c
+			cs.byPath[c.From] = Change{Op: ChangeDelete, Path: c.From}
+		default:
+			cs.byPath[c.Path] = c
+		}
+	}
+	cs.reindex()
+	return cs, nil
+}
+
+// changeSetFromPaths builds a ChangeSet from a plain list of changed
+// paths (e.g. from --include patterns), with Op left
+// at its zero value so makeTree infers create/modify/delete itself.
+func changeSetFromPaths(paths []string) *ChangeSet {
+	cs := &ChangeSet{byPath: make(map[string]Change, len(paths))}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		cs.byPath[path] = Change{Path: path}
+	}
+	cs.reindex()
+	return cs
+}
+
+func (cs *ChangeSet) reindex() {
+	cs.paths = make([]string, 0, len(cs.byPath))
+	for path := range cs.byPath {
+		cs.paths = append(cs.paths, path)
+	}
+	sort.Strings(cs.paths)
+}
+
+// Paths returns every path makeTree needs to consider, sorted.
+func (cs *ChangeSet) Paths() []string {
+	return cs.paths
+}
+
+// Get returns the Change recorded for path, if any.
+func (cs *ChangeSet) Get(path string) (Change, bool) {
+	c, ok := cs.byPath[path]
+	return c, ok
+}
+
+// resolveRenames looks up the pre-rename node for every ChangeRename entry
+// in rootTree, so makeTree can copy its content blobs instead of
+// re-reading the (identical) file data from disk at the new path. A
+// rename whose source can no longer be found (e.g. the source directory
+// itself was deleted) is left unresolved; makeTree then just reads the
+// file normally.
+func (cs *ChangeSet) resolveRenames(ctx context.Context, repo *repository.Repository, rootTree restic.ID) {
+	for path, change := range cs.byPath {
+		if change.Op != ChangeRename {
+			continue
+		}
+		oldNode, err := findNode(ctx, repo, rootTree, change.From)
+		if err != nil {
+			Warnf("could not look up rename source %s: %v; %s will be read from disk instead of reusing its content\n", change.From, err, change.To)
+			continue
+		}
+		change.oldNode = oldNode
+		cs.byPath[path] = change
+	}
+}
+
+// findNode walks rootTree to locate the node at the given absolute path
+// (e.g. "/foo/bar"), as it was stored in the snapshot.
+func findNode(ctx context.Context, repo *repository.Repository, rootTree restic.ID, path string) (*restic.Node, error) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	curID := rootTree
+	var node *restic.Node
+	for i, segment := range segments {
+		tree, err := restic.LoadTree(ctx, repo, curID)
+		if err != nil {
+			return nil, err
+		}
+
+		var found *restic.Node
+		for j := range tree.Nodes {
+			if tree.Nodes[j].Name == segment {
+				found = &tree.Nodes[j]
+				break
+			}
+		}
+		if found == nil {
+			return nil, errors.Errorf("no entry named %q found while resolving %s", segment, path)
+		}
+
+		node = found
+		if i < len(segments)-1 {
+			if found.Type != "dir" || found.Subtree == nil {
+				return nil, errors.Errorf("%s is not a directory", strings.Join(segments[:i+1], "/"))
+			}
+			curID = *found.Subtree
+		}
+	}
+	return node, nil
+}
+
+// readChangesFile reads --changes-file, accepting either a single JSON
+// array of changes or a newline-delimited stream of one JSON object per
+// line.
+func readChangesFile(path string) ([]Change, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	if trimmed[0] == '[' {
+		var changes []Change
+		if err := json.Unmarshal(trimmed, &changes); err != nil {
+			return nil, errors.Fatalf("--changes-file: invalid JSON array: %s", err)
+		}
+		return changes, nil
+	}
+
+	var changes []Change
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var change Change
+		if err := json.Unmarshal(line, &change); err != nil {
+			return nil, errors.Fatalf("--changes-file: invalid JSON line %q: %s", line, err)
+		}
+		changes = append(changes, change)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+// saveTreeFromNodes saves a tree built from pre-constructed nodes, for
+// tests that need fields saveFixtureTree doesn't expose (Content, ModTime).
+func saveTreeFromNodes(t *testing.T, repo *repository.Repository, nodes []restic.Node) restic.ID {
+	t.Helper()
+
+	tree := restic.NewTree(len(nodes))
+	for _, n := range nodes {
+		if err := tree.Insert(n); err != nil {
+			t.Fatalf("Insert(%s): %v", n.Name, err)
+		}
+	}
+	id, err := restic.SaveTree(context.Background(), repo, tree)
+	if err != nil {
+		t.Fatalf("SaveTree: %v", err)
+	}
+	return id
+}
+
+// buildRename returns a resolved ChangeSet for a single rename from->to,
+// as resolveRenames would produce it. The fixture rootTree stands for the
+// directory root rather than for "/", so the source is looked up by its
+// path relative to root; resolveRenames itself resolves from against "/".
+func buildRename(t *testing.T, repo *repository.Repository, rootTree restic.ID, root, from, to string) *ChangeSet {
+	t.Helper()
+
+	changes, err := NewChangeSet([]Change{{Op: ChangeRename, From: from, To: to}})
+	if err != nil {
+		t.Fatalf("NewChangeSet: %v", err)
+	}
+	rel, err := filepath.Rel(root, from)
+	if err != nil {
+		t.Fatalf("Rel: %v", err)
+	}
+	change, _ := changes.Get(to)
+	if oldNode, err := findNode(context.Background(), repo, rootTree, "/"+filepath.ToSlash(rel)); err == nil {
+		change.oldNode = oldNode
+		changes.byPath[to] = change
+	}
+	return changes
+}
+
+// TestMakeTreeRenameDirectoryReusesSubtree is a regression test for the
+// renamed-directory-loses-its-subtree bug: addNodeWithReuse must carry
+// oldNode.Subtree over to the new node instead of leaving it nil.
+func TestMakeTreeRenameDirectoryReusesSubtree(t *testing.T) {
+	repo := repository.TestRepository(t)
+	subID := saveFixtureTree(t, repo, []string{"x.txt"}, nil)
+	rootID := saveFixtureTree(t, repo, []string{"olddir"}, map[string]restic.ID{"olddir": subID})
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "newdir"), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+
+	changes := buildRename(t, repo, rootID, root, filepath.Join(root, "olddir"), filepath.Join(root, "newdir"))
+
+	b := newTestBuild(repo, rootID)
+	b.changes = changes
+	newID, err := makeTree(context.Background(), b, rootID, root+string(filepath.Separator), changes.Paths())
+	if err != nil {
+		t.Fatalf("makeTree: %v", err)
+	}
+
+	tree, err := restic.LoadTree(context.Background(), repo, newID)
+	if err != nil {
+		t.Fatalf("LoadTree: %v", err)
+	}
+	if len(tree.Nodes) != 1 || tree.Nodes[0].Name != "newdir" {
+		t.Fatalf("got nodes %v, want a single \"newdir\" node", loadTreeNodeNames(t, repo, newID))
+	}
+	if tree.Nodes[0].Subtree == nil {
+		t.Fatalf("renamed directory lost its subtree")
+	}
+	if *tree.Nodes[0].Subtree != subID {
+		t.Fatalf("renamed directory's subtree = %v, want the original %v", *tree.Nodes[0].Subtree, subID)
+	}
+}
+
+// TestMakeTreeRenameFileReusesContentWhenMtimeMatches covers the happy
+// path: a renamed file whose size and mtime are unchanged gets its
+// content blobs reused instead of being re-read from disk.
+func TestMakeTreeRenameFileReusesContentWhenMtimeMatches(t *testing.T) {
+	repo := repository.TestRepository(t)
+	mtime := time.Unix(1700000000, 0)
+	blobID := restic.ID{0xaa}
+
+	rootID := saveTreeFromNodes(t, repo, []restic.Node{
+		{Name: "oldname.txt", Type: "file", Size: 5, ModTime: mtime, Content: []restic.ID{blobID}},
+	})
+
+	root := t.TempDir()
+	newPath := filepath.Join(root, "newname.txt")
+	if err := os.WriteFile(newPath, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Chtimes(newPath, mtime, mtime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	changes := buildRename(t, repo, rootID, root, filepath.Join(root, "oldname.txt"), newPath)
+
+	b := newTestBuild(repo, rootID)
+	b.changes = changes
+	newID, err := makeTree(context.Background(), b, rootID, root+string(filepath.Separator), changes.Paths())
+	if err != nil {
+		t.Fatalf("makeTree: %v", err)
+	}
+
+	tree, err := restic.LoadTree(context.Background(), repo, newID)
+	if err != nil {
+		t.Fatalf("LoadTree: %v", err)
+	}
+	if len(tree.Nodes) != 1 || len(tree.Nodes[0].Content) != 1 || tree.Nodes[0].Content[0] != blobID {
+		t.Fatalf("got nodes %+v, want a single node reusing blob %v", tree.Nodes, blobID)
+	}
+	if got := b.reusedFiles.Load(); got != 1 {
+		t.Errorf("reusedFiles = %d, want 1", got)
+	}
+	if got := b.rechunkedFiles.Load(); got != 0 {
+		t.Errorf("rechunkedFiles = %d, want 0", got)
+	}
+}
+
+// TestMakeTreeRenameFileRechunksWhenMtimeDiffers covers a rename batched
+// with a same-length content edit before the flush: the mtime no longer
+// matches, so the stale content must not be reused.
+func TestMakeTreeRenameFileRechunksWhenMtimeDiffers(t *testing.T) {
+	repo := repository.TestRepository(t)
+	oldMtime := time.Unix(1700000000, 0)
+	newMtime := time.Unix(1700000100, 0)
+	blobID := restic.ID{0xaa}
+
+	rootID := saveTreeFromNodes(t, repo, []restic.Node{
+		{Name: "oldname.txt", Type: "file", Size: 5, ModTime: oldMtime, Content: []restic.ID{blobID}},
+	})
+
+	root := t.TempDir()
+	newPath := filepath.Join(root, "newname.txt")
+	if err := os.WriteFile(newPath, []byte("HELLO"), 0o644); err != nil { // same size, different content
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Chtimes(newPath, newMtime, newMtime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	changes := buildRename(t, repo, rootID, root, filepath.Join(root, "oldname.txt"), newPath)
+
+	b := newTestBuild(repo, rootID)
+	b.changes = changes
+	newID, err := makeTree(context.Background(), b, rootID, root+string(filepath.Separator), changes.Paths())
+	if err != nil {
+		t.Fatalf("makeTree: %v", err)
+	}
+
+	tree, err := restic.LoadTree(context.Background(), repo, newID)
+	if err != nil {
+		t.Fatalf("LoadTree: %v", err)
+	}
+	if len(tree.Nodes) != 1 {
+		t.Fatalf("got nodes %+v, want a single node", tree.Nodes)
+	}
+	if len(tree.Nodes[0].Content) == 1 && tree.Nodes[0].Content[0] == blobID {
+		t.Fatalf("node reused stale content blob %v despite a mismatched mtime", blobID)
+	}
+	if got := b.reusedFiles.Load(); got != 0 {
+		t.Errorf("reusedFiles = %d, want 0", got)
+	}
+	if got := b.rechunkedFiles.Load(); got != 1 {
+		t.Errorf("rechunkedFiles = %d, want 1", got)
+	}
+}
+
+// TestMakeTreeRenameUnresolvedSourceFallsBackToDisk covers a rename whose
+// source can no longer be found (e.g. its directory was itself deleted):
+// resolveRenames leaves oldNode nil, and addNodeWithReuse must treat the
+// destination as a fresh file rather than reuse (or panic on) a nil node.
+func TestMakeTreeRenameUnresolvedSourceFallsBackToDisk(t *testing.T) {
+	repo := repository.TestRepository(t)
+	rootID := saveFixtureTree(t, repo, nil, nil)
+
+	root := t.TempDir()
+	newPath := filepath.Join(root, "newname.txt")
+	if err := os.WriteFile(newPath, []byte("hello"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	changes := buildRename(t, repo, rootID, root, filepath.Join(root, "doesnotexist.txt"), newPath)
+
+	b := newTestBuild(repo, rootID)
+	b.changes = changes
+	newID, err := makeTree(context.Background(), b, rootID, root+string(filepath.Separator), changes.Paths())
+	if err != nil {
+		t.Fatalf("makeTree: %v", err)
+	}
+
+	assertNames(t, loadTreeNodeNames(t, repo, newID), []string{"newname.txt"})
+	if got := b.rechunkedFiles.Load(); got != 1 {
+		t.Errorf("rechunkedFiles = %d, want 1", got)
+	}
+}
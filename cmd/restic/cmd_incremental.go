@@ -2,20 +2,30 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"runtime"
 	"sort"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/restic/restic/internal/backend"
 	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fswatch"
 	"github.com/restic/restic/internal/repository"
 	"github.com/restic/restic/internal/restic"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 )
 
+// treeCacheSize bounds the number of decoded trees makeTree keeps around
+// for prefetching; it is a small multiple of the default tree-concurrency
+// so that a full level of siblings can be prefetched without eviction.
+const treeCacheSize = 256
+
 var cmdIncremental = &cobra.Command{
 	Use:   "incremental [flags]",
 	Short: "Perform an incremental backup based on given list of changed files",
@@ -25,6 +35,19 @@ and modifies it during the copy to reflect the changes to the list of files prov
 
 If no files are provided via the *include* arguments, the command will exit early and make no changes.
 
+Instead of *include* patterns, a --changes-file can be given with an explicit, structured list of changes: a JSON
+array or newline-delimited JSON stream of objects with an "op" of "create", "modify", "delete" or "rename". A
+"rename" entry additionally carries "from" and "to" paths; makeTree removes the node at "from" and inserts one at
+"to", reusing its content blobs when the file's size and modification time are unchanged, instead of re-reading it from disk. This avoids
+having to infer deletions from a bare os.IsNotExist check, which cannot distinguish a delete from a rename.
+
+Alternatively, passing --watch runs the command as a long-running process that collects changed paths itself via
+fanotify (or inotify, on kernels where fanotify is unavailable to the current user), without needing an external
+auditd pipeline. Changes are accumulated for --watch-interval before each flush creates a new snapshot; the
+process keeps running and creating further snapshots until it is stopped, e.g. with SIGINT or SIGTERM. Note that
+the privileged fanotify path only observes content and metadata changes to existing files; create/delete/rename detection
+requires the unprivileged inotify fallback.
+
 Please note, no changes are made to the existing snapshot, only to the copy that is created during this commands runtime. If anything goes wrong,
 no backed-up data will be lose or damaged.
 
@@ -44,6 +67,15 @@ type IncrementalOptions struct {
 	Metadata snapshotMetadataArgs
 	restic.SnapshotFilter
 	includePatternOptions
+
+	Watch         bool
+	WatchInterval time.Duration
+
+	TreeConcurrency int
+
+	ChangesFile string
+
+	ReuseUnchangedContent bool
 }
 
 func init() {
@@ -53,11 +85,63 @@ func init() {
 
 	initMultiSnapshotFilter(f, &incrementalOptions.SnapshotFilter, true)
 	initIncludePatternOptions(f, &incrementalOptions.includePatternOptions)
+
+	f.BoolVar(&incrementalOptions.Watch, "watch", false, "watch the snapshot's paths for changes instead of reading --include patterns once, creating a new snapshot every --watch-interval")
+	f.DurationVar(&incrementalOptions.WatchInterval, "watch-interval", 30*time.Second, "how long to accumulate changes from --watch before flushing them into a new snapshot")
+
+	f.IntVar(&incrementalOptions.TreeConcurrency, "tree-concurrency", runtime.GOMAXPROCS(0), "number of subtrees to rebuild concurrently while making the incremental tree")
+
+	f.StringVar(&incrementalOptions.ChangesFile, "changes-file", "", "read a structured list of create/modify/delete/rename changes from `file` (JSON array or newline-delimited JSON), instead of --include patterns")
+
+	f.BoolVar(&incrementalOptions.ReuseUnchangedContent, "reuse-unchanged-content", true, "reuse a file's existing content blobs instead of re-reading it from disk, if its size and modification time match the parent snapshot")
 }
 
 var incrementalOptions IncrementalOptions
 
-func addNode(path string, tb *restic.TreeJSONBuilder, tree *restic.Tree) error {
+// treeBuild holds the state shared by every makeTree call within a single
+// incremental run: the repository and change list being applied, the
+// worker-pool plumbing added for concurrency, and the parent snapshot's
+// root tree plus running counters used to decide whether a file's content
+// can be reused instead of re-read from disk.
+type treeBuild struct {
+	repo    *repository.Repository
+	changes *ChangeSet
+	cache   *treeCache
+	sem     chan struct{}
+
+	rootTree       restic.ID
+	reuseUnchanged bool
+	reusedFiles    atomic.Int64
+	rechunkedFiles atomic.Int64
+}
+
+// acquire takes a --tree-concurrency slot, blocking until one is free or
+// ctx is canceled. Every makeTree call acquires a slot for its own
+// (non-recursive) work and releases it before recursing into children, so
+// that a slot is never held across a wait on descendants that need slots
+// from the same pool; holding across that wait is what deadlocks a single
+// change nested deeper than --tree-concurrency.
+func (b *treeBuild) acquire(ctx context.Context) error {
+	select {
+	case b.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release returns a slot acquired via acquire.
+func (b *treeBuild) release() {
+	<-b.sem
+}
+
+// addNode builds the node for a new or modified file at path. When
+// reuseUnchanged is enabled, it first looks up the file at the same path
+// in the parent snapshot; if size and modification time are unchanged,
+// its content blobs are reused instead of letting the file be re-read and
+// re-chunked, since a metadata-only change (e.g. a touch) leaves the
+// actual data untouched.
+func (b *treeBuild) addNode(ctx context.Context, path string, tb *restic.TreeJSONBuilder, tree *restic.Tree) error {
 	fileInfo, err := os.Stat(path)
 	if err != nil {
 		return err
@@ -66,135 +150,325 @@ func addNode(path string, tb *restic.TreeJSONBuilder, tree *restic.Tree) error {
 	if err != nil {
 		return err
 	}
-	err = tree.Insert(newNode)
+
+	if b.reuseUnchanged && newNode.Type == "file" {
+		if oldNode, err := findNode(ctx, b.repo, b.rootTree, path); err == nil {
+			b.tryReuse(path, oldNode, newNode)
+		}
+	}
+
+	if err := tree.Insert(newNode); err != nil {
+		return err
+	}
+	return tb.AddNode(newNode)
+}
+
+// addNodeWithReuse builds the node for a renamed path. When oldNode is a
+// directory, its subtree is carried over directly, since a rename doesn't
+// touch the directory's contents. When it's a file whose size and
+// modification time both still match (the same bar addNode's tryReuse
+// applies, since a rename batched together with a same-length content
+// edit before the next flush must not be treated as unchanged), its
+// content blobs are reused instead of letting them be re-chunked from
+// disk.
+func (b *treeBuild) addNodeWithReuse(path string, oldNode *restic.Node, tb *restic.TreeJSONBuilder, tree *restic.Tree) error {
+	fileInfo, err := os.Stat(path)
 	if err != nil {
 		return err
 	}
-	err = tb.AddNode(newNode)
+	newNode, err := restic.NodeFromFileInfo(path, fileInfo, true)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	switch {
+	case oldNode != nil && oldNode.Type == "dir" && newNode.Type == "dir":
+		Verbosef("path %s was renamed from %s, reusing its existing subtree\n", path, oldNode.Name)
+		newNode.Subtree = oldNode.Subtree
+	case oldNode != nil && oldNode.Type == "file" && oldNode.Size == newNode.Size && oldNode.ModTime.Equal(newNode.ModTime):
+		Verbosef("path %s was renamed from %s, reusing its existing content\n", path, oldNode.Name)
+		newNode.Content = oldNode.Content
+		b.reusedFiles.Add(1)
+	case newNode.Type == "file":
+		b.rechunkedFiles.Add(1)
+	}
+
+	if err := tree.Insert(newNode); err != nil {
+		return err
+	}
+	return tb.AddNode(newNode)
 }
 
-func makeTree(ctx context.Context, repo *repository.Repository, nodeID restic.ID, dir string, includePaths []string) (newNodeID restic.ID, err error) {
+// tryReuse copies oldNode's content blobs into newNode when the file's
+// size and modification time both match, and tallies the outcome either
+// way. newNode is mutated in place.
+func (b *treeBuild) tryReuse(path string, oldNode *restic.Node, newNode *restic.Node) {
+	if oldNode.Type != "file" || oldNode.Size != newNode.Size || !oldNode.ModTime.Equal(newNode.ModTime) {
+		b.rechunkedFiles.Add(1)
+		return
+	}
+
+	Verbosef("path %s is unchanged since the parent snapshot, reusing its existing content\n", path)
+	newNode.Content = oldNode.Content
+	b.reusedFiles.Add(1)
+}
+
+// planEntry is one slot of the new tree being assembled by makeTree, in
+// final (sorted) order. Entries are discovered serially in a first pass
+// over the current tree, but the "dir" entries are only resolved once the
+// recursive makeTree call for their subtree returns, which may happen
+// concurrently with sibling directories.
+type planEntry struct {
+	kind    planKind
+	path    string      // kind == planFile, planRename
+	node    restic.Node // kind == planExisting, planDir
+	keys    []string    // kind == planDir: includePaths to recurse with
+	oldNode *restic.Node
+}
+
+type planKind int
+
+const (
+	planExisting planKind = iota
+	planFile
+	planDir
+	planRename
+)
+
+// dirJob is the work handed to the bounded worker pool: resolve the new
+// subtree ID for one planDir entry.
+type dirJob struct {
+	planIndex int
+	subtree   restic.ID
+	dir       string
+	keys      []string
+}
+
+func makeTree(ctx context.Context, b *treeBuild, nodeID restic.ID, dir string, includePaths []string) (newNodeID restic.ID, err error) {
 	// If 0 paths are in includePaths, we can return the given nodeID as we know this and any sub-trees are not going to be modified
 	if len(includePaths) == 0 {
 		return nodeID, nil
 	}
-	// Load the tree for the given nodeID
-	curTree, err := restic.LoadTree(ctx, repo, nodeID)
+
+	// Acquire a --tree-concurrency slot for the first (non-recursive) pass
+	// below; it is released again before we recurse into any children and
+	// reacquired for the final assembly pass, see treeBuild.acquire. held
+	// tracks which of those two windows we are currently in, so the single
+	// deferred release below is a no-op during the window where we don't
+	// hold a slot.
+	if err := b.acquire(ctx); err != nil {
+		return restic.ID{}, err
+	}
+	held := true
+	release := func() {
+		if held {
+			b.release()
+			held = false
+		}
+	}
+	defer release()
+
+	// Load the tree for the given nodeID, serving it from the prefetch cache if a sibling call already warmed it
+	curTree, err := loadTreeCached(ctx, b.repo, b.cache, nodeID)
 	if err != nil {
 		return restic.ID{}, err
 	}
-	// Create a newTreeJSONBuilder and a new restic tree, as we will need to create both
-	tb := restic.NewTreeJSONBuilder()
-	tree := restic.NewTree(len(curTree.Nodes) + len(includePaths))
-	// Mark any includePaths which are not either in this directory, or a sub-dir of this directory for removal
-	remove := make([]bool, len(includePaths))
-	for j := range remove {
-		if !strings.HasPrefix(includePaths[j], dir) {
-			remove[j] = true
+
+	// First pass (cheap, serial): decide what goes into the new tree and in
+	// what order, without yet touching disk or recursing. Directory entries
+	// are left unresolved until the second pass.
+	//
+	// includePaths arrives sorted, so we split it once into:
+	//   - directByName: changes to an entry directly inside this directory,
+	//     keyed by basename, for a sorted merge against curTree.Nodes.
+	//   - subdirKeys: changes nested under a child directory of this one,
+	//     grouped by that child's name and handed down for it to filter
+	//     further when it recurses.
+	// A sorted merge of curTree.Nodes and directByName's keys then replaces
+	// the old O(len(Nodes)*len(includePaths)) nested loop, and naturally
+	// covers insertions before the first node, after the last node, and
+	// into an empty directory, without a special case for any of them.
+	directByName := make(map[string]string)
+	subdirKeys := make(map[string][]string)
+	for _, path := range includePaths {
+		if path == "" || !strings.HasPrefix(path, dir) {
+			continue
+		}
+		remainder := path[len(dir):]
+		if idx := strings.IndexByte(remainder, '/'); idx >= 0 {
+			subdirName := remainder[:idx]
+			subdirKeys[subdirName] = append(subdirKeys[subdirName], path)
 		} else {
-			remove[j] = false
+			directByName[remainder] = path
 		}
 	}
-	// Iterate over all the nodes for the current tree
-	for i, node := range curTree.Nodes {
-		// skipNode will be true if a file is modified or deleted
-		skipNode := false
-		for j, path := range includePaths {
-			// If path has been marked for removal by another node, or from the above check, skip it
-			if remove[j] {
-				continue
-			}
-			// Ensure we ignore blank paths
-			if path == "" {
-				continue
-			}
-			// Check if the current included path matches the full path of the node we are checking
-			if dir+node.Name == path {
-				// Path was either modified or deleted
-				_, err := os.Stat(path)
-				skipNode = true
-				// If the path doesn't exist on the FS, we can skip doing anything for the path
-				if os.IsNotExist(err) {
-					Verbosef("path %s was deleted on the FS, skipping adding it to the tree\n", path)
-					continue
+	directNames := make([]string, 0, len(directByName))
+	for name := range directByName {
+		directNames = append(directNames, name)
+	}
+	sort.Strings(directNames)
+
+	plan := make([]planEntry, 0, len(curTree.Nodes)+len(directNames))
+	var dirJobs []dirJob
+	consumedSubdirs := make(map[string]bool, len(subdirKeys))
+
+	ni, di := 0, 0
+	for ni < len(curTree.Nodes) || di < len(directNames) {
+		var node restic.Node
+		hasNode := ni < len(curTree.Nodes)
+		if hasNode {
+			node = curTree.Nodes[ni]
+		}
+		var directName, path string
+		hasDirect := di < len(directNames)
+		if hasDirect {
+			directName = directNames[di]
+			path = directByName[directName]
+		}
+
+		switch {
+		case hasNode && (!hasDirect || node.Name < directName):
+			// No direct change for this node; it still needs to recurse if
+			// changes were nested underneath it.
+			if node.Type == "dir" {
+				keys := subdirKeys[node.Name]
+				consumedSubdirs[node.Name] = true
+				if len(keys) > 0 {
+					sort.Strings(keys)
+					var subtree restic.ID
+					if node.Subtree != nil {
+						subtree = *node.Subtree
+					}
+					plan = append(plan, planEntry{kind: planDir, node: node, keys: keys})
+					dirJobs = append(dirJobs, dirJob{planIndex: len(plan) - 1, subtree: subtree, dir: dir + node.Name + "/", keys: keys})
+				} else {
+					plan = append(plan, planEntry{kind: planExisting, node: node})
 				}
-				Verbosef("path %s was modified on the FS, generating a new node and adding it to the tree\n", path)
+			} else {
+				plan = append(plan, planEntry{kind: planExisting, node: node})
 			}
-			// If the current path lives in the same dir as the current node, and the current nodes name is greater than the current paths,
-			// we want to insert a new node for our file in before the current node from the old tree
-			if skipNode || (filepath.Dir(dir+node.Name) == filepath.Dir(path) && node.Name > filepath.Base(path)) {
-				if !skipNode {
+			ni++
+
+		case hasDirect && (!hasNode || directName < node.Name):
+			// A new file, inserted in sort order; this also covers
+			// insertions before the first node and after the last one.
+			change, _ := b.changes.Get(path)
+			switch change.Op {
+			case ChangeDelete:
+				// Already gone and never existed at this position; nothing to insert.
+			case ChangeRename:
+				plan = append(plan, planEntry{kind: planRename, path: path, oldNode: change.oldNode})
+			case ChangeCreate, ChangeModify:
+				Verbosef("path %s is new on the FS, generating a new node and adding it to the tree\n", path)
+				plan = append(plan, planEntry{kind: planFile, path: path})
+			default:
+				// Op is unknown, e.g. this path came from --include rather
+				// than --changes-file: fall back to inferring it by
+				// stating the filesystem, as makeTree always has.
+				if _, err := os.Stat(path); err == nil {
 					Verbosef("path %s is new on the FS, generating a new node and adding it to the tree\n", path)
-				}
-				err = addNode(path, tb, tree)
-				if err != nil {
+					plan = append(plan, planEntry{kind: planFile, path: path})
+				} else if !os.IsNotExist(err) {
 					return restic.ID{}, err
 				}
-				// Mark file for removal so it's not included in the next recursive call to makeTree
-				remove[j] = true
 			}
-		}
-		// If skipNode is true, then don't insert the current node from the old tree into the new one, as we already did this above
-		if !skipNode {
-			// This piece is taken from the rewrite section
-			if node.Type != "dir" {
-				err := tree.Insert(node)
-				if err != nil {
-					return restic.ID{}, err
-				}
-				err = tb.AddNode(node)
-				if err != nil {
+			di++
+
+		default:
+			// Names match: the node was either modified, deleted, or renamed away.
+			change, _ := b.changes.Get(path)
+			switch change.Op {
+			case ChangeDelete:
+				Verbosef("path %s was deleted on the FS, skipping adding it to the tree\n", path)
+			case ChangeRename:
+				plan = append(plan, planEntry{kind: planRename, path: path, oldNode: change.oldNode})
+			case ChangeCreate, ChangeModify:
+				Verbosef("path %s was modified on the FS, generating a new node and adding it to the tree\n", path)
+				plan = append(plan, planEntry{kind: planFile, path: path})
+			default:
+				if _, err := os.Stat(path); err == nil {
+					Verbosef("path %s was modified on the FS, generating a new node and adding it to the tree\n", path)
+					plan = append(plan, planEntry{kind: planFile, path: path})
+				} else if !os.IsNotExist(err) {
 					return restic.ID{}, err
+				} else {
+					Verbosef("path %s was deleted on the FS, skipping adding it to the tree\n", path)
 				}
-				continue
-			}
-			var subtree restic.ID
-			if node.Subtree != nil {
-				subtree = *node.Subtree
 			}
-			var keys []string
-			for j, path := range includePaths {
-				if !remove[j] {
-					keys = append(keys, path)
+			ni++
+			di++
+		}
+	}
+	for name := range subdirKeys {
+		if !consumedSubdirs[name] {
+			Verbosef("path(s) under %s%s/ do not correspond to an existing directory in the snapshot, skipping\n", dir, name)
+		}
+	}
+
+	// Second pass: resolve every planDir entry. Prefetch every subtree this
+	// level is about to recurse into (unbounded), then recurse into each
+	// one. We release our own --tree-concurrency slot before recursing (the
+	// recursive makeTree call acquires its own slot internally) so that
+	// this goroutine never holds a slot while blocked in group.Wait() for
+	// descendants that need a slot from the same pool; without that, a
+	// single change nested deeper than --tree-concurrency deadlocks every
+	// slot against an ancestor waiting on a descendant that can never run.
+	if len(dirJobs) > 0 {
+		ids := make([]restic.ID, len(dirJobs))
+		for i, job := range dirJobs {
+			ids[i] = job.subtree
+		}
+		b.cache.prefetch(ctx, b.repo, ids)
+
+		release()
+
+		group, gctx := errgroup.WithContext(ctx)
+		for _, job := range dirJobs {
+			job := job
+			group.Go(func() error {
+				newID, err := makeTree(gctx, b, job.subtree, job.dir, job.keys)
+				if err != nil {
+					return err
 				}
-			}
-			sort.Strings(keys)
-			// If the current node is a dir, recurse into makeTree with the current nodes subtree, and iter over that tree
-			newID, err := makeTree(ctx, repo, subtree, dir+node.Name+"/", keys)
-			if err != nil {
+				plan[job.planIndex].node.Subtree = &newID
+				return nil
+			})
+		}
+		if err := group.Wait(); err != nil {
+			return restic.ID{}, err
+		}
+
+		if err := b.acquire(ctx); err != nil {
+			return restic.ID{}, err
+		}
+		held = true
+	}
+
+	// Third pass: assemble the new tree and its JSON representation now
+	// that every child ID is known, in the deterministic order decided by
+	// the first pass above.
+	tb := restic.NewTreeJSONBuilder()
+	tree := restic.NewTree(len(plan))
+	for _, entry := range plan {
+		switch entry.kind {
+		case planFile:
+			if err := b.addNode(ctx, entry.path, tb, tree); err != nil {
 				return restic.ID{}, err
 			}
-			// We will have a new resticID for the subtree, so apply it here
-			node.Subtree = &newID
-			err = tree.Insert(node)
-			if err != nil {
+		case planRename:
+			if err := b.addNodeWithReuse(entry.path, entry.oldNode, tb, tree); err != nil {
 				return restic.ID{}, err
 			}
-			err = tb.AddNode(node)
-			if err != nil {
+		case planExisting, planDir:
+			if err := tree.Insert(entry.node); err != nil {
 				return restic.ID{}, err
 			}
-			// If we are on the last iteration for the current tree, we need to check if any nodes need to be inserted at the end of the tree
-			if i == len(curTree.Nodes)-1 {
-				for _, path := range keys {
-					// If the parent dir matches for any remaining paths in includePaths, we know they need to be inserted here, as all
-					// other nodes in this dir would have been inserted into the tree and removed from the slice in the earlier section
-					if filepath.Dir(dir+node.Name) == filepath.Dir(path) {
-						Verbosef("path %s is new on the FS, generating a new node and adding it to the tree\n", path)
-						err = addNode(path, tb, tree)
-						if err != nil {
-							return restic.ID{}, err
-						}
-					}
-				}
+			if err := tb.AddNode(entry.node); err != nil {
+				return restic.ID{}, err
 			}
 		}
 	}
+
 	// Get the JSON for the tree
 	treeJSON, err := tb.Finalize()
 	if err != nil {
@@ -202,12 +476,12 @@ func makeTree(ctx context.Context, repo *repository.Repository, nodeID restic.ID
 	}
 
 	// Save new tree
-	id, err := restic.SaveTree(ctx, repo, tree)
+	id, err := restic.SaveTree(ctx, b.repo, tree)
 	if err != nil {
 		return restic.ID{}, err
 	}
 	// Save the JSON
-	_, err = repo.SaveUnpacked(ctx, backend.PackFile, treeJSON)
+	_, err = b.repo.SaveUnpacked(ctx, backend.PackFile, treeJSON)
 	if err != nil {
 		return restic.ID{}, err
 	}
@@ -215,9 +489,27 @@ func makeTree(ctx context.Context, repo *repository.Repository, nodeID restic.ID
 	return id, nil
 }
 
+// validateTreeConcurrency rejects a --tree-concurrency that would make
+// make(chan struct{}, n) either panic (n < 0) or never admit a single
+// worker (n == 0), hanging makeTree forever.
+func validateTreeConcurrency(n int) error {
+	if n < 1 {
+		return errors.Fatalf("--tree-concurrency must be at least 1, got %d", n)
+	}
+	return nil
+}
+
 func runIncremental(ctx context.Context, opts IncrementalOptions, gopts GlobalOptions) error {
-	if opts.includePatternOptions.Empty() && opts.Metadata.empty() {
-		return errors.Fatal("Nothing to do: no includes provided and no new metadata provided")
+	if opts.Watch {
+		return runIncrementalWatch(ctx, opts, gopts)
+	}
+
+	if opts.includePatternOptions.Empty() && opts.Metadata.empty() && opts.ChangesFile == "" {
+		return errors.Fatal("Nothing to do: no includes provided, no --changes-file provided, and no new metadata provided")
+	}
+
+	if err := validateTreeConcurrency(opts.TreeConcurrency); err != nil {
+		return err
 	}
 
 	var (
@@ -248,22 +540,45 @@ func runIncremental(ctx context.Context, opts IncrementalOptions, gopts GlobalOp
 	wg, ctx := errgroup.WithContext(ctx)
 	repo.StartPackUploader(ctx, wg)
 
-	// Get a list of all the paths which have changed (new files, modified files, deleted files)
-	includePaths, err := opts.includePatternOptions.GetPathsFromPatterns()
-	if err != nil {
-		return err
+	// Build the set of changes to apply: either a structured --changes-file, or
+	// a plain list of paths from --include patterns (deletions inferred by makeTree via os.Stat)
+	var changes *ChangeSet
+	if opts.ChangesFile != "" {
+		rawChanges, err := readChangesFile(opts.ChangesFile)
+		if err != nil {
+			return err
+		}
+		changes, err = NewChangeSet(rawChanges)
+		if err != nil {
+			return err
+		}
+		changes.resolveRenames(ctx, repo, *sn.Tree)
+		Verbosef("got %d changes from --changes-file\n", len(changes.Paths()))
+	} else {
+		includePaths, err := opts.includePatternOptions.GetPathsFromPatterns()
+		if err != nil {
+			return err
+		}
+		Verbosef("got %d paths from include patterns\n", len(includePaths))
+		changes = changeSetFromPaths(includePaths)
 	}
 
-	Verbosef("got %d paths from include patterns\n", len(includePaths))
-
-	// Sort the paths to ensure we insert nodes in the correct order when building the tree
-	sort.Strings(includePaths)
-
 	// Call the recursive makeTree funciton, which makes the tree with the changed files included (or excluded for deletions)
-	treeID, err := makeTree(ctx, repo, *sn.Tree, "/", includePaths)
+	b := &treeBuild{
+		repo:           repo,
+		changes:        changes,
+		cache:          newTreeCache(treeCacheSize),
+		sem:            make(chan struct{}, opts.TreeConcurrency),
+		rootTree:       *sn.Tree,
+		reuseUnchanged: opts.ReuseUnchangedContent,
+	}
+	treeID, err := makeTree(ctx, b, *sn.Tree, "/", changes.Paths())
 	if err != nil {
 		return err
 	}
+	if b.reuseUnchanged {
+		Verbosef("reused content for %d unchanged files, re-chunked %d files\n", b.reusedFiles.Load(), b.rechunkedFiles.Load())
+	}
 
 	err = repo.Flush(ctx)
 	if err != nil {
@@ -285,3 +600,162 @@ func runIncremental(ctx context.Context, opts IncrementalOptions, gopts GlobalOp
 
 	return nil
 }
+
+// watchChangeOp maps an fswatch event to the matching ChangeOp, so a
+// --watch flush can be turned directly into a ChangeSet without having to
+// re-derive create/modify/delete by stating the filesystem.
+func watchChangeOp(op fswatch.Op) ChangeOp {
+	switch op {
+	case fswatch.OpCreate:
+		return ChangeCreate
+	case fswatch.OpDelete:
+		return ChangeDelete
+	default:
+		return ChangeModify
+	}
+}
+
+// watchFlush is emitted as one JSON line per flush interval when --watch
+// and --json are both set.
+type watchFlush struct {
+	SnapshotID     string `json:"snapshot_id"`
+	ChangedPaths   int    `json:"changed_paths"`
+	ReusedFiles    int64  `json:"reused_files"`
+	RechunkedFiles int64  `json:"rechunked_files"`
+}
+
+// runIncrementalWatch implements `incremental --watch`: it watches the
+// most recent snapshot's paths for changes using internal/fswatch and,
+// every WatchInterval, folds the accumulated changes into a new snapshot
+// using the same makeTree logic as the one-shot mode above.
+func runIncrementalWatch(ctx context.Context, opts IncrementalOptions, gopts GlobalOptions) error {
+	if err := validateTreeConcurrency(opts.TreeConcurrency); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var (
+		repo   *repository.Repository
+		unlock func()
+		err    error
+	)
+
+	ctx, repo, unlock, err = openWithAppendLock(ctx, gopts, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	bar := newIndexProgress(gopts.Quiet, gopts.JSON)
+	if err = repo.LoadIndex(ctx, bar); err != nil {
+		return err
+	}
+
+	sn, _, err := opts.SnapshotFilter.FindLatest(ctx, repo, repo, "latest")
+	if err != nil {
+		return err
+	}
+
+	Verbosef("loaded snapshot %v, watching %v for changes\n", sn.ID().Str(), sn.Paths)
+
+	watcher, err := fswatch.New(fswatch.Options{
+		Roots:         sn.Paths,
+		FlushInterval: opts.WatchInterval,
+	})
+	if err != nil {
+		return err
+	}
+
+	wg, ctx := errgroup.WithContext(ctx)
+	repo.StartPackUploader(ctx, wg)
+
+	watchErr := make(chan error, 1)
+	go func() {
+		watchErr <- watcher.Run(ctx)
+	}()
+
+	curTree := *sn.Tree
+	curSnapshot := sn
+	b := &treeBuild{
+		repo:           repo,
+		cache:          newTreeCache(treeCacheSize),
+		sem:            make(chan struct{}, opts.TreeConcurrency),
+		rootTree:       *sn.Tree,
+		reuseUnchanged: opts.ReuseUnchangedContent,
+	}
+
+	for {
+		select {
+		case err := <-watchErr:
+			return err
+		case <-ctx.Done():
+			return nil
+		case batch, ok := <-watcher.Events():
+			if !ok {
+				return <-watchErr
+			}
+
+			watchChanges := make([]Change, len(batch))
+			for i, ev := range batch {
+				watchChanges[i] = Change{Op: watchChangeOp(ev.Op), Path: ev.Path}
+			}
+			changes, err := NewChangeSet(watchChanges)
+			if err != nil {
+				return err
+			}
+			includePaths := changes.Paths()
+			b.changes = changes
+			reusedBefore, rechunkedBefore := b.reusedFiles.Load(), b.rechunkedFiles.Load()
+
+			treeID, err := makeTree(ctx, b, curTree, "/", includePaths)
+			if err != nil {
+				return err
+			}
+
+			if err := repo.Flush(ctx); err != nil {
+				return err
+			}
+
+			newSnapshot, err := restic.NewSnapshot(curSnapshot.Paths, curSnapshot.Tags, curSnapshot.Hostname, time.Now())
+			if err != nil {
+				return err
+			}
+			newSnapshot.Tree = &treeID
+
+			id, err := restic.SaveSnapshot(ctx, repo, newSnapshot)
+			if err != nil {
+				return err
+			}
+
+			reusedNow := b.reusedFiles.Load() - reusedBefore
+			rechunkedNow := b.rechunkedFiles.Load() - rechunkedBefore
+
+			if gopts.JSON {
+				err := json.NewEncoder(gopts.stdout).Encode(watchFlush{
+					SnapshotID:     id.String(),
+					ChangedPaths:   len(includePaths),
+					ReusedFiles:    reusedNow,
+					RechunkedFiles: rechunkedNow,
+				})
+				if err != nil {
+					return err
+				}
+			} else {
+				Verbosef("saved new snapshot %v from %d changed paths (reused %d, re-chunked %d)\n", id.Str(), len(includePaths), reusedNow, rechunkedNow)
+			}
+
+			curTree = treeID
+			newSnapshot.Tree = &curTree
+			curSnapshot = newSnapshot
+			// b.rootTree must track the most recently saved snapshot, not
+			// just the one --watch started from: addNode's reuse check
+			// compares against it, and a stale rootTree would compare a
+			// later flush's files against pre-watch size+mtime instead of
+			// the last flush's, reusing content that may have changed in
+			// between.
+			b.rootTree = curTree
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+// saveFixtureTree saves a tree with the given node names to repo and
+// returns its ID. dirs maps a subset of names to the ID of their subtree;
+// every other name is saved as a small file.
+func saveFixtureTree(t *testing.T, repo *repository.Repository, names []string, dirs map[string]restic.ID) restic.ID {
+	t.Helper()
+
+	tree := restic.NewTree(len(names))
+	for _, name := range names {
+		node := restic.Node{Name: name}
+		if subtree, ok := dirs[name]; ok {
+			id := subtree
+			node.Type = "dir"
+			node.Subtree = &id
+		} else {
+			node.Type = "file"
+			node.Size = 1
+		}
+		if err := tree.Insert(node); err != nil {
+			t.Fatalf("Insert(%s): %v", name, err)
+		}
+	}
+
+	id, err := restic.SaveTree(context.Background(), repo, tree)
+	if err != nil {
+		t.Fatalf("SaveTree: %v", err)
+	}
+	return id
+}
+
+// saveNestedFixtureTree saves a chain of depth single-entry directories,
+// each named "d", bottoming out in a directory containing one file named
+// "file.txt", and returns the root tree ID.
+func saveNestedFixtureTree(t *testing.T, repo *repository.Repository, depth int) restic.ID {
+	t.Helper()
+
+	id := saveFixtureTree(t, repo, []string{"file.txt"}, nil)
+	for i := 0; i < depth; i++ {
+		id = saveFixtureTree(t, repo, []string{"d"}, map[string]restic.ID{"d": id})
+	}
+	return id
+}
+
+// writeNestedFile creates root/d/d/.../file.txt, depth levels deep, and
+// returns its full path.
+func writeNestedFile(t *testing.T, root string, depth int, content []byte) string {
+	t.Helper()
+
+	dir := root
+	for i := 0; i < depth; i++ {
+		dir = filepath.Join(dir, "d")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	path := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+// TestMakeTreeDeepNestingWithLowConcurrency is a regression test for a
+// deadlock where a goroutine held its --tree-concurrency slot for the
+// entire duration of its recursive makeTree call, including while blocked
+// in its own group.Wait() for children that need a slot from the same
+// pool. A single change nested deeper than --tree-concurrency used to
+// starve every slot this way; with --tree-concurrency=1 even two levels of
+// nesting was enough to hang forever.
+func TestMakeTreeDeepNestingWithLowConcurrency(t *testing.T) {
+	const depth = 6
+	repo := repository.TestRepository(t)
+	rootID := saveNestedFixtureTree(t, repo, depth)
+
+	root := t.TempDir()
+	changedPath := writeNestedFile(t, root, depth, []byte("changed"))
+
+	b := &treeBuild{
+		repo:  repo,
+		cache: newTreeCache(treeCacheSize),
+		sem:   make(chan struct{}, 1),
+	}
+	b.changes = changeSetFromPaths([]string{changedPath})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := makeTree(ctx, b, rootID, root+string(filepath.Separator), b.changes.Paths()); err != nil {
+		t.Fatalf("makeTree deadlocked or failed: %v", err)
+	}
+}
+
+// TestMakeTreeConcurrencyMatchesSerial verifies that the concurrent,
+// prefetching makeTree produces the exact same tree ID as a serial
+// (--tree-concurrency=1) run over the same change set.
+func TestMakeTreeConcurrencyMatchesSerial(t *testing.T) {
+	const depth = 4
+	repo := repository.TestRepository(t)
+	rootID := saveNestedFixtureTree(t, repo, depth)
+
+	root := t.TempDir()
+	changedPath := writeNestedFile(t, root, depth, []byte("changed"))
+
+	run := func(concurrency int) restic.ID {
+		b := &treeBuild{
+			repo:  repo,
+			cache: newTreeCache(treeCacheSize),
+			sem:   make(chan struct{}, concurrency),
+		}
+		b.changes = changeSetFromPaths([]string{changedPath})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		id, err := makeTree(ctx, b, rootID, root+string(filepath.Separator), b.changes.Paths())
+		if err != nil {
+			t.Fatalf("makeTree(concurrency=%d): %v", concurrency, err)
+		}
+		return id
+	}
+
+	serial := run(1)
+	parallel := run(8)
+
+	if serial != parallel {
+		t.Fatalf("tree ID mismatch between concurrency=1 (%v) and concurrency=8 (%v)", serial, parallel)
+	}
+}
+
+// TestValidateTreeConcurrency checks that out-of-range --tree-concurrency
+// values are rejected before they reach make(chan struct{}, n), which
+// panics for n < 0 and hangs forever for n == 0.
+func TestValidateTreeConcurrency(t *testing.T) {
+	for _, n := range []int{-1, 0} {
+		if err := validateTreeConcurrency(n); err == nil {
+			t.Errorf("validateTreeConcurrency(%d) = nil, want error", n)
+		}
+	}
+	for _, n := range []int{1, 4} {
+		if err := validateTreeConcurrency(n); err != nil {
+			t.Errorf("validateTreeConcurrency(%d) = %v, want nil", n, err)
+		}
+	}
+}
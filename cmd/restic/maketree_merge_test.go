@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+// loadTreeNodeNames loads id and returns its node names, in the order
+// makeTree stored them (which must match the sorted-merge output order).
+func loadTreeNodeNames(t *testing.T, repo *repository.Repository, id restic.ID) []string {
+	t.Helper()
+
+	tree, err := restic.LoadTree(context.Background(), repo, id)
+	if err != nil {
+		t.Fatalf("LoadTree: %v", err)
+	}
+	names := make([]string, len(tree.Nodes))
+	for i, node := range tree.Nodes {
+		names[i] = node.Name
+	}
+	return names
+}
+
+func assertNames(t *testing.T, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func newTestBuild(repo *repository.Repository, rootTree restic.ID) *treeBuild {
+	return &treeBuild{
+		repo:     repo,
+		cache:    newTreeCache(treeCacheSize),
+		sem:      make(chan struct{}, 2),
+		rootTree: rootTree,
+	}
+}
+
+// TestMakeTreeInsertIntoEmptyDirectory covers an insertion into a
+// directory with zero existing nodes, which the old O(N*M) loop missed:
+// its only handling of trailing insertions was a last-node special case,
+// and an empty directory has no last node.
+func TestMakeTreeInsertIntoEmptyDirectory(t *testing.T) {
+	repo := repository.TestRepository(t)
+	rootID := saveFixtureTree(t, repo, nil, nil)
+
+	root := t.TempDir()
+	newPath := filepath.Join(root, "new.txt")
+	if err := os.WriteFile(newPath, []byte("x"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	changes, err := NewChangeSet([]Change{{Op: ChangeCreate, Path: newPath}})
+	if err != nil {
+		t.Fatalf("NewChangeSet: %v", err)
+	}
+
+	b := newTestBuild(repo, rootID)
+	b.changes = changes
+	newID, err := makeTree(context.Background(), b, rootID, root+string(filepath.Separator), changes.Paths())
+	if err != nil {
+		t.Fatalf("makeTree: %v", err)
+	}
+
+	assertNames(t, loadTreeNodeNames(t, repo, newID), []string{"new.txt"})
+}
+
+// TestMakeTreeAllDeletesKeepsEmptyDirectory covers a directory whose every
+// node is deleted in the same batch: makeTree keeps the directory entry
+// itself (it still exists on disk; only its old contents are gone) but
+// its subtree becomes empty.
+func TestMakeTreeAllDeletesKeepsEmptyDirectory(t *testing.T) {
+	repo := repository.TestRepository(t)
+	subID := saveFixtureTree(t, repo, []string{"a.txt", "b.txt"}, nil)
+	rootID := saveFixtureTree(t, repo, []string{"d"}, map[string]restic.ID{"d": subID})
+
+	changes, err := NewChangeSet([]Change{
+		{Op: ChangeDelete, Path: "/d/a.txt"},
+		{Op: ChangeDelete, Path: "/d/b.txt"},
+	})
+	if err != nil {
+		t.Fatalf("NewChangeSet: %v", err)
+	}
+
+	b := newTestBuild(repo, rootID)
+	b.changes = changes
+	newID, err := makeTree(context.Background(), b, rootID, "/", changes.Paths())
+	if err != nil {
+		t.Fatalf("makeTree: %v", err)
+	}
+
+	assertNames(t, loadTreeNodeNames(t, repo, newID), []string{"d"})
+
+	tree, err := restic.LoadTree(context.Background(), repo, newID)
+	if err != nil {
+		t.Fatalf("LoadTree: %v", err)
+	}
+	if tree.Nodes[0].Subtree == nil {
+		t.Fatalf("dir node %q lost its subtree entirely", tree.Nodes[0].Name)
+	}
+	assertNames(t, loadTreeNodeNames(t, repo, *tree.Nodes[0].Subtree), nil)
+}
+
+// TestMakeTreeCrossesDirectoryBoundaries covers a single batch with both a
+// direct sibling change and a change nested under a subdirectory, which
+// exercises the split between directByName and subdirKeys.
+func TestMakeTreeCrossesDirectoryBoundaries(t *testing.T) {
+	repo := repository.TestRepository(t)
+	subID := saveFixtureTree(t, repo, []string{"x.txt"}, nil)
+	rootID := saveFixtureTree(t, repo, []string{"a", "b.txt"}, map[string]restic.ID{"a": subID})
+
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "a"), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	newUnderA := filepath.Join(root, "a", "y.txt")
+	if err := os.WriteFile(newUnderA, []byte("y"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	modifiedSibling := filepath.Join(root, "b.txt")
+	if err := os.WriteFile(modifiedSibling, []byte("changed"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	changes, err := NewChangeSet([]Change{
+		{Op: ChangeCreate, Path: newUnderA},
+		{Op: ChangeModify, Path: modifiedSibling},
+	})
+	if err != nil {
+		t.Fatalf("NewChangeSet: %v", err)
+	}
+
+	b := newTestBuild(repo, rootID)
+	b.changes = changes
+	newID, err := makeTree(context.Background(), b, rootID, root+string(filepath.Separator), changes.Paths())
+	if err != nil {
+		t.Fatalf("makeTree: %v", err)
+	}
+
+	assertNames(t, loadTreeNodeNames(t, repo, newID), []string{"a", "b.txt"})
+
+	tree, err := restic.LoadTree(context.Background(), repo, newID)
+	if err != nil {
+		t.Fatalf("LoadTree: %v", err)
+	}
+	if tree.Nodes[0].Subtree == nil {
+		t.Fatalf("dir node %q lost its subtree", tree.Nodes[0].Name)
+	}
+	assertNames(t, loadTreeNodeNames(t, repo, *tree.Nodes[0].Subtree), []string{"x.txt", "y.txt"})
+}
+
+// TestMakeTreeInsertAfterLastNode covers an insertion whose name sorts
+// after every existing node in the directory.
+func TestMakeTreeInsertAfterLastNode(t *testing.T) {
+	repo := repository.TestRepository(t)
+	rootID := saveFixtureTree(t, repo, []string{"a.txt"}, nil)
+
+	root := t.TempDir()
+	newPath := filepath.Join(root, "z.txt")
+	if err := os.WriteFile(newPath, []byte("z"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	changes, err := NewChangeSet([]Change{{Op: ChangeCreate, Path: newPath}})
+	if err != nil {
+		t.Fatalf("NewChangeSet: %v", err)
+	}
+
+	b := newTestBuild(repo, rootID)
+	b.changes = changes
+	newID, err := makeTree(context.Background(), b, rootID, root+string(filepath.Separator), changes.Paths())
+	if err != nil {
+		t.Fatalf("makeTree: %v", err)
+	}
+
+	assertNames(t, loadTreeNodeNames(t, repo, newID), []string{"a.txt", "z.txt"})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// treeCache is a small, concurrency-safe LRU cache of decoded trees, keyed
+// by restic.ID. makeTree uses it to prefetch the next level of subtrees
+// while the current level is still being assembled, instead of paying for
+// restic.LoadTree serially on every recursive call.
+type treeCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[restic.ID]*list.Element
+}
+
+type treeCacheEntry struct {
+	id   restic.ID
+	tree *restic.Tree
+}
+
+// newTreeCache returns a treeCache holding at most capacity trees.
+func newTreeCache(capacity int) *treeCache {
+	return &treeCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[restic.ID]*list.Element),
+	}
+}
+
+func (c *treeCache) get(id restic.ID) (*restic.Tree, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[id]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*treeCacheEntry).tree, true
+}
+
+func (c *treeCache) add(id restic.ID, tree *restic.Tree) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[id]; ok {
+		c.ll.MoveToFront(el)
+		el.Value.(*treeCacheEntry).tree = tree
+		return
+	}
+
+	el := c.ll.PushFront(&treeCacheEntry{id: id, tree: tree})
+	c.items[id] = el
+
+	for c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		if oldest == nil {
+			break
+		}
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*treeCacheEntry).id)
+	}
+}
+
+// loadTreeCached returns the tree for id, serving it from cache when
+// possible and populating the cache on a miss. cache may be nil, in which
+// case it behaves like a plain restic.LoadTree.
+func loadTreeCached(ctx context.Context, repo restic.Loader, cache *treeCache, id restic.ID) (*restic.Tree, error) {
+	if cache != nil {
+		if tree, ok := cache.get(id); ok {
+			return tree, nil
+		}
+	}
+
+	tree, err := restic.LoadTree(ctx, repo, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if cache != nil {
+		cache.add(id, tree)
+	}
+	return tree, nil
+}
+
+// prefetch warms the cache for ids concurrently and unboundedly (prefetch
+// is deliberately not limited by --tree-concurrency, since the point is to
+// hide LoadTree latency ahead of when a worker actually needs the tree).
+// Errors are ignored here; the real loadTreeCached call made by the worker
+// that needs the tree will surface any problem to the caller.
+func (c *treeCache) prefetch(ctx context.Context, repo restic.Loader, ids []restic.ID) {
+	zero := restic.ID{}
+	for _, id := range ids {
+		if id == zero {
+			continue
+		}
+		if _, ok := c.get(id); ok {
+			continue
+		}
+		go func(id restic.ID) {
+			tree, err := restic.LoadTree(ctx, repo, id)
+			if err != nil {
+				return
+			}
+			c.add(id, tree)
+		}(id)
+	}
+}
@@ -0,0 +1,91 @@
+// Package fswatch watches parts of the local filesystem for changes and
+// reports them as a batched stream of events. It exists so that commands
+// like "incremental" can learn which paths changed directly from the
+// kernel, instead of depending on an external pipeline (e.g. auditd)
+// to feed them a list of changed files.
+package fswatch
+
+import (
+	"context"
+	"time"
+)
+
+// Op describes the kind of filesystem change that was observed.
+type Op int
+
+// The set of change kinds a Watcher can report. Renames are not detected
+// at this layer; the inotify and polling watchers report them as a Delete
+// for the old name and a Create for the new one, and the fanotify watcher
+// does not report them at all.
+const (
+	OpCreate Op = iota
+	OpModify
+	OpDelete
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpCreate:
+		return "create"
+	case OpModify:
+		return "modify"
+	case OpDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a single filesystem change detected by a Watcher.
+type Event struct {
+	Path string
+	Op   Op
+}
+
+// Options configures a Watcher.
+type Options struct {
+	// Roots are the paths to watch, recursively.
+	Roots []string
+	// FlushInterval is how often accumulated events are delivered on the
+	// channel returned by Events. Events for the same path that arrive
+	// within one interval are coalesced, keeping only the most recent Op.
+	FlushInterval time.Duration
+}
+
+// Watcher accumulates filesystem events under a set of root paths and
+// delivers them in batches, once per FlushInterval.
+type Watcher interface {
+	// Run starts the watcher and blocks until ctx is canceled or an
+	// unrecoverable error occurs. Callers should run it in its own
+	// goroutine and read from Events until it returns.
+	Run(ctx context.Context) error
+	// Events returns the channel on which batches of events are delivered.
+	// It is closed once Run returns.
+	Events() <-chan []Event
+}
+
+// New creates a Watcher appropriate for the current platform: fanotify on
+// Linux (falling back to inotify when fanotify is unavailable, e.g. for
+// unprivileged processes), and a portable polling watcher elsewhere.
+func New(opts Options) (Watcher, error) {
+	return newWatcher(opts)
+}
+
+// coalesce merges a batch of events, keeping only the most recent Op for
+// each path and preserving the order in which paths were first touched.
+func coalesce(events []Event) []Event {
+	order := make([]string, 0, len(events))
+	latest := make(map[string]Op, len(events))
+	for _, ev := range events {
+		if _, ok := latest[ev.Path]; !ok {
+			order = append(order, ev.Path)
+		}
+		latest[ev.Path] = ev.Op
+	}
+
+	out := make([]Event, 0, len(order))
+	for _, path := range order {
+		out = append(out, Event{Path: path, Op: latest[path]})
+	}
+	return out
+}
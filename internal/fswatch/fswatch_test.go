@@ -0,0 +1,36 @@
+package fswatch
+
+import "testing"
+
+func TestCoalesce(t *testing.T) {
+	events := []Event{
+		{Path: "/a", Op: OpCreate},
+		{Path: "/b", Op: OpModify},
+		{Path: "/a", Op: OpModify},
+		{Path: "/c", Op: OpCreate},
+		{Path: "/a", Op: OpDelete},
+	}
+
+	got := coalesce(events)
+
+	want := []Event{
+		{Path: "/a", Op: OpDelete},
+		{Path: "/b", Op: OpModify},
+		{Path: "/c", Op: OpCreate},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("coalesce(%v) = %v, want %v", events, got, want)
+	}
+	for i, ev := range want {
+		if got[i] != ev {
+			t.Fatalf("coalesce(%v)[%d] = %v, want %v", events, i, got[i], ev)
+		}
+	}
+}
+
+func TestCoalesceEmpty(t *testing.T) {
+	if got := coalesce(nil); len(got) != 0 {
+		t.Fatalf("coalesce(nil) = %v, want empty", got)
+	}
+}
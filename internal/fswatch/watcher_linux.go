@@ -0,0 +1,270 @@
+//go:build linux
+
+package fswatch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// linuxWatcher is backed by fanotify when the process has the required
+// privileges (CAP_SYS_ADMIN), and falls back to inotify otherwise. Both
+// report events per-watched-directory, so in either case we walk the
+// roots up front and add a watch for every directory found.
+//
+// The two backends aren't quite equivalent: fanotify here only reports
+// content/metadata changes to existing files (FAN_MODIFY/FAN_ATTRIB), not
+// create/delete/rename, since those dirent events require FAN_REPORT_FID
+// on fanotify_init() and the file-handle-based records that mode produces
+// instead of the fd-based ones decodeFanotify parses. Only the inotify
+// fallback observes create/delete/rename, so a privileged caller that
+// needs those should run unprivileged (or this needs the FAN_REPORT_FID
+// rewrite instead).
+type linuxWatcher struct {
+	opts Options
+	fd   int
+	// useInotify is true when fanotify init failed and we fell back to
+	// inotify; it only changes how raw kernel events are decoded.
+	useInotify bool
+	// wd tracks inotify watch descriptors back to their directory, since
+	// inotify events only carry the wd and a relative name.
+	wd map[int32]string
+
+	events chan []Event
+}
+
+func newWatcher(opts Options) (Watcher, error) {
+	w := &linuxWatcher{
+		opts:   opts,
+		wd:     make(map[int32]string),
+		events: make(chan []Event),
+	}
+
+	fd, err := unix.FanotifyInit(unix.FAN_CLASS_NOTIF|unix.FAN_CLOEXEC, unix.O_RDONLY)
+	if err != nil {
+		// Most commonly EPERM for unprivileged processes; fall back to inotify.
+		fd, err = unix.InotifyInit1(unix.IN_CLOEXEC)
+		if err != nil {
+			return nil, fmt.Errorf("fswatch: neither fanotify nor inotify are available: %w", err)
+		}
+		w.useInotify = true
+	}
+	w.fd = fd
+
+	if err := w.addRoots(); err != nil {
+		_ = unix.Close(w.fd)
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *linuxWatcher) addRoots() error {
+	for _, root := range w.opts.Roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return nil
+			}
+			return w.addDir(path)
+		})
+		if err != nil {
+			return fmt.Errorf("fswatch: walking %s: %w", root, err)
+		}
+	}
+	return nil
+}
+
+func (w *linuxWatcher) addDir(path string) error {
+	if w.useInotify {
+		mask := uint32(unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ATTRIB)
+		wd, err := unix.InotifyAddWatch(w.fd, path, mask)
+		if err != nil {
+			return fmt.Errorf("inotify_add_watch %s: %w", path, err)
+		}
+		w.wd[int32(wd)] = path
+		return nil
+	}
+
+	// FAN_CREATE/FAN_DELETE/FAN_MOVED_FROM/FAN_MOVED_TO ("dirent events") are
+	// deliberately not requested here: they require FAN_REPORT_FID on the
+	// fanotify_init() call, which changes every event to a file-handle-based
+	// record instead of the fd-based ones decodeFanotify parses, and
+	// FanotifyMark would fail with EINVAL if we marked for them without it.
+	// So the privileged fanotify path only reports content/metadata changes
+	// to files that already exist; create/delete/rename is inotify-only,
+	// see newWatcher.
+	mask := uint64(unix.FAN_MODIFY | unix.FAN_ATTRIB | unix.FAN_ONDIR | unix.FAN_EVENT_ON_CHILD)
+	if err := unix.FanotifyMark(w.fd, unix.FAN_MARK_ADD, mask, -1, path); err != nil {
+		return fmt.Errorf("fanotify_mark %s: %w", path, err)
+	}
+	return nil
+}
+
+func (w *linuxWatcher) Events() <-chan []Event {
+	return w.events
+}
+
+func (w *linuxWatcher) Run(ctx context.Context) error {
+	defer close(w.events)
+	defer unix.Close(w.fd)
+
+	raw := make(chan Event)
+	readErr := make(chan error, 1)
+	go w.readLoop(ctx, raw, readErr)
+
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+
+	var pending []Event
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			return err
+		case ev := <-raw:
+			pending = append(pending, ev)
+		case <-ticker.C:
+			if len(pending) == 0 {
+				continue
+			}
+			batch := coalesce(pending)
+			pending = nil
+			select {
+			case w.events <- batch:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// readLoop decodes raw fanotify/inotify events from the fd and forwards
+// them one at a time on raw, until ctx is canceled.
+func (w *linuxWatcher) readLoop(ctx context.Context, raw chan<- Event, errs chan<- error) {
+	buf := make([]byte, 64*1024)
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		n, err := unix.Read(w.fd, buf)
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			errs <- fmt.Errorf("fswatch: reading events: %w", err)
+			return
+		}
+
+		var evs []Event
+		if w.useInotify {
+			evs = w.decodeInotify(buf[:n])
+		} else {
+			evs = w.decodeFanotify(buf[:n])
+		}
+
+		for _, ev := range evs {
+			select {
+			case raw <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// fanotifyDeletedSuffix is appended by the kernel to the /proc/self/fd
+// symlink target of an fd whose dentry has already been unlinked. That
+// happens when a file is removed after a FAN_MODIFY/FAN_ATTRIB event for
+// it was queued but before the event is read, so the suffix has to be
+// stripped back off to recover the real path.
+const fanotifyDeletedSuffix = " (deleted)"
+
+// decodeFanotify reads FAN_CLASS_NOTIF metadata records. We only requested
+// FAN_REPORT_FID-less (path based) notification, so each record's fd refers
+// to the changed file itself; we resolve its path via /proc/self/fd and
+// close it once read.
+func (w *linuxWatcher) decodeFanotify(buf []byte) []Event {
+	var out []Event
+	off := 0
+	for off+unix.SizeofFanotifyEventMetadata <= len(buf) {
+		meta := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&buf[off]))
+		if meta.Fd >= 0 {
+			path, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", meta.Fd))
+			if err == nil {
+				// If the file was unlinked after the event was queued, the
+				// symlink target carries the "(deleted)" marker instead of
+				// a bare path; strip it so the event's path still matches
+				// a tree node.
+				path = strings.TrimSuffix(path, fanotifyDeletedSuffix)
+				out = append(out, Event{Path: path, Op: fanotifyOp(meta.Mask)})
+			}
+			_ = unix.Close(int(meta.Fd))
+		}
+		if meta.EventLen == 0 {
+			break
+		}
+		off += int(meta.EventLen)
+	}
+	return out
+}
+
+// fanotifyOp always reports OpModify: addDir only ever marks for
+// FAN_MODIFY/FAN_ATTRIB, since dirent events (create/delete/rename)
+// require FAN_REPORT_FID, which we don't request (see linuxWatcher).
+func fanotifyOp(mask uint64) Op {
+	return OpModify
+}
+
+func (w *linuxWatcher) decodeInotify(buf []byte) []Event {
+	var out []Event
+	off := 0
+	for off+unix.SizeofInotifyEvent <= len(buf) {
+		raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+		nameLen := int(raw.Len)
+		nameStart := off + unix.SizeofInotifyEvent
+		name := ""
+		if nameLen > 0 {
+			name = cString(buf[nameStart : nameStart+nameLen])
+		}
+
+		dir := w.wd[raw.Wd]
+		if dir != "" && name != "" {
+			out = append(out, Event{Path: filepath.Join(dir, name), Op: inotifyOp(raw.Mask)})
+		}
+
+		off = nameStart + nameLen
+	}
+	return out
+}
+
+func inotifyOp(mask uint32) Op {
+	switch {
+	case mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+		return OpCreate
+	case mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+		return OpDelete
+	default:
+		return OpModify
+	}
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
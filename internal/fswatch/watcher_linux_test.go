@@ -0,0 +1,158 @@
+//go:build linux
+
+package fswatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// fanotifyMetadataBytes builds a single raw FanotifyEventMetadata record,
+// the same wire format decodeFanotify parses out of the fd it reads.
+func fanotifyMetadataBytes(t *testing.T, fd int32, mask uint64) []byte {
+	t.Helper()
+	buf := make([]byte, unix.SizeofFanotifyEventMetadata)
+	meta := (*unix.FanotifyEventMetadata)(unsafe.Pointer(&buf[0]))
+	meta.EventLen = uint32(unix.SizeofFanotifyEventMetadata)
+	meta.Vers = unix.FANOTIFY_METADATA_VERSION
+	meta.Mask = mask
+	meta.Fd = fd
+	meta.Pid = int32(os.Getpid())
+	return buf
+}
+
+// openDup opens path and returns a dup()'d fd the caller owns, so
+// decodeFanotify's own unix.Close of it doesn't interfere with the
+// original *os.File (or the test's own cleanup).
+func openDup(t *testing.T, path string) int32 {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+
+	dup, err := unix.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatalf("Dup: %v", err)
+	}
+	return int32(dup)
+}
+
+func TestDecodeFanotifyResolvesPath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	w := &linuxWatcher{}
+	events := w.decodeFanotify(fanotifyMetadataBytes(t, openDup(t, path), unix.FAN_MODIFY))
+
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1: %v", len(events), events)
+	}
+	if events[0].Path != path {
+		t.Errorf("path = %q, want %q", events[0].Path, path)
+	}
+	if events[0].Op != OpModify {
+		t.Errorf("op = %v, want OpModify", events[0].Op)
+	}
+}
+
+// TestDecodeFanotifyStripsDeletedSuffix is a regression test for the
+// corrupted-path bug fixed in decodeFanotify: once a file is unlinked, the
+// /proc/self/fd symlink target for an fd still open on it carries a
+// "(deleted)" suffix that must be stripped back off.
+func TestDecodeFanotifyStripsDeletedSuffix(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gone.txt")
+	if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	fd := openDup(t, path)
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	w := &linuxWatcher{}
+	events := w.decodeFanotify(fanotifyMetadataBytes(t, fd, unix.FAN_MODIFY))
+
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1: %v", len(events), events)
+	}
+	if events[0].Path != path {
+		t.Errorf("path = %q, want %q (the \"(deleted)\" marker should be stripped)", events[0].Path, path)
+	}
+}
+
+func TestFanotifyOpIsAlwaysModify(t *testing.T) {
+	// addDir only ever marks FAN_MODIFY/FAN_ATTRIB (dirent events require
+	// FAN_REPORT_FID, which we don't request), so every mask fanotifyOp
+	// actually sees in practice maps to OpModify.
+	for _, mask := range []uint64{unix.FAN_MODIFY, unix.FAN_ATTRIB, unix.FAN_MODIFY | unix.FAN_ONDIR} {
+		if op := fanotifyOp(mask); op != OpModify {
+			t.Errorf("fanotifyOp(%#x) = %v, want OpModify", mask, op)
+		}
+	}
+}
+
+// inotifyEventBytes builds a single raw inotify_event record, including
+// its NUL-terminated name, the same wire format decodeInotify parses.
+func inotifyEventBytes(wd int32, mask uint32, name string) []byte {
+	nameBytes := append([]byte(name), 0)
+	buf := make([]byte, int(unix.SizeofInotifyEvent)+len(nameBytes))
+	ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[0]))
+	ev.Wd = wd
+	ev.Mask = mask
+	ev.Len = uint32(len(nameBytes))
+	copy(buf[unix.SizeofInotifyEvent:], nameBytes)
+	return buf
+}
+
+func TestDecodeInotifyResolvesPathFromWatchDescriptor(t *testing.T) {
+	w := &linuxWatcher{wd: map[int32]string{7: "/watched/dir"}}
+
+	events := w.decodeInotify(inotifyEventBytes(7, unix.IN_CREATE, "new.txt"))
+
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1: %v", len(events), events)
+	}
+	if want := filepath.Join("/watched/dir", "new.txt"); events[0].Path != want {
+		t.Errorf("path = %q, want %q", events[0].Path, want)
+	}
+	if events[0].Op != OpCreate {
+		t.Errorf("op = %v, want OpCreate", events[0].Op)
+	}
+}
+
+func TestDecodeInotifyIgnoresUnknownWatchDescriptor(t *testing.T) {
+	w := &linuxWatcher{wd: map[int32]string{}}
+
+	events := w.decodeInotify(inotifyEventBytes(99, unix.IN_MODIFY, "x.txt"))
+
+	if len(events) != 0 {
+		t.Fatalf("got %d events, want 0 for an unknown watch descriptor: %v", len(events), events)
+	}
+}
+
+func TestDecodeInotifyMultipleEventsInOneBuffer(t *testing.T) {
+	w := &linuxWatcher{wd: map[int32]string{1: "/a"}}
+
+	buf := append(inotifyEventBytes(1, unix.IN_DELETE, "x.txt"), inotifyEventBytes(1, unix.IN_MOVED_TO, "y.txt")...)
+	events := w.decodeInotify(buf)
+
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2: %v", len(events), events)
+	}
+	if events[0].Path != "/a/x.txt" || events[0].Op != OpDelete {
+		t.Errorf("events[0] = %+v, want {/a/x.txt OpDelete}", events[0])
+	}
+	if events[1].Path != "/a/y.txt" || events[1].Op != OpCreate {
+		t.Errorf("events[1] = %+v, want {/a/y.txt OpCreate}", events[1])
+	}
+}
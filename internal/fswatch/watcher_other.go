@@ -0,0 +1,95 @@
+//go:build !linux
+
+package fswatch
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pollWatcher implements Watcher on platforms without fanotify/inotify by
+// periodically re-walking the roots and diffing mtimes. It is less
+// efficient than the Linux watcher but needs no special privileges.
+type pollWatcher struct {
+	opts   Options
+	events chan []Event
+	seen   map[string]time.Time
+}
+
+func newWatcher(opts Options) (Watcher, error) {
+	return &pollWatcher{
+		opts:   opts,
+		events: make(chan []Event),
+		seen:   make(map[string]time.Time),
+	}, nil
+}
+
+func (w *pollWatcher) Events() <-chan []Event {
+	return w.events
+}
+
+func (w *pollWatcher) Run(ctx context.Context) error {
+	defer close(w.events)
+
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			batch, err := w.scan()
+			if err != nil {
+				return err
+			}
+			if len(batch) == 0 {
+				continue
+			}
+			select {
+			case w.events <- batch:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// scan walks the roots once, comparing mtimes against the previous scan
+// to build a batch of create/modify/delete events.
+func (w *pollWatcher) scan() ([]Event, error) {
+	current := make(map[string]time.Time, len(w.seen))
+	var events []Event
+
+	for _, root := range w.opts.Roots {
+		err := filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			mtime := info.ModTime()
+			current[path] = mtime
+
+			if prev, ok := w.seen[path]; !ok {
+				events = append(events, Event{Path: path, Op: OpCreate})
+			} else if !prev.Equal(mtime) {
+				events = append(events, Event{Path: path, Op: OpModify})
+			}
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	for path := range w.seen {
+		if _, ok := current[path]; !ok {
+			events = append(events, Event{Path: path, Op: OpDelete})
+		}
+	}
+
+	w.seen = current
+	return coalesce(events), nil
+}
@@ -0,0 +1,64 @@
+//go:build !linux
+
+package fswatch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPollWatcherScanDetectsCreateModifyDelete(t *testing.T) {
+	dir := t.TempDir()
+	kept := filepath.Join(dir, "kept.txt")
+	removed := filepath.Join(dir, "removed.txt")
+
+	if err := os.WriteFile(kept, []byte("1"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(removed, []byte("1"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	w := &pollWatcher{opts: Options{Roots: []string{dir}}, seen: make(map[string]time.Time)}
+
+	// First scan only ever observes creates, since w.seen starts empty.
+	first, err := w.scan()
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(first) != 3 { // dir itself + the two files
+		t.Fatalf("first scan: got %d events, want 3: %v", len(first), first)
+	}
+
+	if err := os.Remove(removed); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	// Sleep long enough that mtime granularity can't mask the modification.
+	time.Sleep(10 * time.Millisecond)
+	if err := os.WriteFile(kept, []byte("12"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	second, err := w.scan()
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+
+	var sawModify, sawDelete bool
+	for _, ev := range second {
+		switch {
+		case ev.Path == kept && ev.Op == OpModify:
+			sawModify = true
+		case ev.Path == removed && ev.Op == OpDelete:
+			sawDelete = true
+		}
+	}
+	if !sawModify {
+		t.Errorf("second scan: missing OpModify for %s: %v", kept, second)
+	}
+	if !sawDelete {
+		t.Errorf("second scan: missing OpDelete for %s: %v", removed, second)
+	}
+}